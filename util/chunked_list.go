@@ -0,0 +1,54 @@
+package util
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/pager"
+
+	wfv1 "github.com/argoproj/argo/v2/pkg/apis/workflow/v1alpha1"
+	wfclientset "github.com/argoproj/argo/v2/pkg/client/clientset/versioned"
+)
+
+// ListWorkflowsChunked walks all Workflows in namespace matching selector in pages of chunkSize,
+// invoking fn once per page, so that large namespaces can be enumerated with bounded memory
+// instead of a single giant List RPC. It wraps client-go's generic pager via EachListItem. If the
+// server returns 410 Gone for an expired Continue token, the walk stops and that error is
+// returned; no fresh list is started, and pages already passed to fn are not delivered again.
+// namespace may be metav1.NamespaceAll to list across all namespaces.
+func ListWorkflowsChunked(ctx context.Context, clientSet wfclientset.Interface, namespace, selector string, chunkSize int64, fn func(*wfv1.WorkflowList) error) error {
+	listFunc := func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+		opts.FieldSelector = selector
+		return clientSet.ArgoprojV1alpha1().Workflows(namespace).List(ctx, opts)
+	}
+	p := pager.New(pager.SimplePageFunc(listFunc))
+	p.PageSize = chunkSize
+
+	var page []wfv1.Workflow
+	flush := func() error {
+		if len(page) == 0 {
+			return nil
+		}
+		chunk := &wfv1.WorkflowList{Items: page}
+		page = nil
+		return fn(chunk)
+	}
+
+	err := p.EachListItem(ctx, metav1.ListOptions{}, func(obj runtime.Object) error {
+		wf, ok := obj.(*wfv1.Workflow)
+		if !ok {
+			return fmt.Errorf("unexpected object type %T while listing workflows", obj)
+		}
+		page = append(page, *wf)
+		if int64(len(page)) >= chunkSize {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return flush()
+}
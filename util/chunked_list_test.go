@@ -0,0 +1,86 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	wfv1 "github.com/argoproj/argo/v2/pkg/apis/workflow/v1alpha1"
+	wffake "github.com/argoproj/argo/v2/pkg/client/clientset/versioned/fake"
+)
+
+func newTestWorkflowClientset(namespace string, n int) *wffake.Clientset {
+	objs := make([]runtime.Object, 0, n)
+	for i := 0; i < n; i++ {
+		objs = append(objs, &wfv1.Workflow{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("wf-%d", i),
+				Namespace: namespace,
+			},
+		})
+	}
+	return wffake.NewSimpleClientset(objs...)
+}
+
+// collectPages drives ListWorkflowsChunked and records the size of every page fn was called with,
+// plus the Workflow names seen across all pages combined.
+func collectPages(t *testing.T, clientSet *wffake.Clientset, chunkSize int64) (pageSizes []int, names []string) {
+	t.Helper()
+	err := ListWorkflowsChunked(context.Background(), clientSet, metav1.NamespaceAll, "", chunkSize, func(list *wfv1.WorkflowList) error {
+		pageSizes = append(pageSizes, len(list.Items))
+		for _, wf := range list.Items {
+			names = append(names, wf.Name)
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	return pageSizes, names
+}
+
+func TestListWorkflowsChunkedPageBoundariesAndLeftoverFlush(t *testing.T) {
+	clientSet := newTestWorkflowClientset("default", 5)
+
+	pageSizes, names := collectPages(t, clientSet, 2)
+
+	assert.Equal(t, []int{2, 2, 1}, pageSizes, "a leftover partial page should be flushed after the loop")
+	assert.Len(t, names, 5)
+}
+
+func TestListWorkflowsChunkedSizeLargerThanTotalItems(t *testing.T) {
+	clientSet := newTestWorkflowClientset("default", 3)
+
+	pageSizes, names := collectPages(t, clientSet, 10)
+
+	assert.Equal(t, []int{3}, pageSizes, "everything should be flushed as a single leftover page")
+	assert.Len(t, names, 3)
+}
+
+func TestListWorkflowsChunkedNoItems(t *testing.T) {
+	clientSet := newTestWorkflowClientset("default", 0)
+
+	pageSizes, names := collectPages(t, clientSet, 2)
+
+	assert.Empty(t, pageSizes, "fn must not be called when there is nothing to flush")
+	assert.Empty(t, names)
+}
+
+func TestListWorkflowsChunkedPropagatesFnErrorMidStream(t *testing.T) {
+	clientSet := newTestWorkflowClientset("default", 5)
+
+	callCount := 0
+	boom := fmt.Errorf("boom")
+	err := ListWorkflowsChunked(context.Background(), clientSet, metav1.NamespaceAll, "", 2, func(list *wfv1.WorkflowList) error {
+		callCount++
+		if callCount == 2 {
+			return boom
+		}
+		return nil
+	})
+
+	assert.ErrorIs(t, err, boom)
+	assert.Equal(t, 2, callCount, "fn must not be invoked again once it has returned an error")
+}
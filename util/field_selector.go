@@ -0,0 +1,87 @@
+package util
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/fields"
+)
+
+type fieldSelectorClause struct {
+	field string
+	value string
+}
+
+// FieldSelectorBuilder composes multiple field selector predicates (names, phases, namespace,
+// ...) with AND semantics, producing the same kind of selector string that
+// GenerateFieldSelectorFromWorkflowName produces for a single name.
+type FieldSelectorBuilder struct {
+	clauses []fieldSelectorClause
+}
+
+// NewFieldSelectorBuilder returns an empty builder.
+func NewFieldSelectorBuilder() *FieldSelectorBuilder {
+	return &FieldSelectorBuilder{}
+}
+
+// WithName ANDs in a "metadata.name=" clause.
+func (b *FieldSelectorBuilder) WithName(name string) *FieldSelectorBuilder {
+	if name != "" {
+		b.clauses = append(b.clauses, fieldSelectorClause{field: "metadata.name", value: name})
+	}
+	return b
+}
+
+// WithNamespace ANDs in a "metadata.namespace=" clause.
+func (b *FieldSelectorBuilder) WithNamespace(namespace string) *FieldSelectorBuilder {
+	if namespace != "" {
+		b.clauses = append(b.clauses, fieldSelectorClause{field: "metadata.namespace", value: namespace})
+	}
+	return b
+}
+
+// WithPhase ANDs in a "status.phase=" clause.
+func (b *FieldSelectorBuilder) WithPhase(phase string) *FieldSelectorBuilder {
+	if phase != "" {
+		b.clauses = append(b.clauses, fieldSelectorClause{field: "status.phase", value: phase})
+	}
+	return b
+}
+
+// recoverFieldSelectorValue extracts the value of field= out of selector, the same way
+// RecoverWorkflowNameFromSelectorStringIfAny does for "metadata.name=", generalized to any field.
+func recoverFieldSelectorValue(selector, field string) string {
+	tag := field + "="
+	if starts := strings.Index(selector, tag); starts > -1 {
+		suffix := selector[starts+len(tag):]
+		if ends := strings.Index(suffix, ","); ends > -1 {
+			return strings.TrimSpace(suffix[:ends])
+		}
+		return strings.TrimSpace(suffix)
+	}
+	return ""
+}
+
+// Build renders the composed selector string, then round-trips each clause back through
+// recoverFieldSelectorValue and panics if the recovered value doesn't match what was passed to
+// WithName/WithNamespace/WithPhase (mirroring GenerateFieldSelectorFromWorkflowName's self-test).
+// This catches values containing characters like ',' or '=' that would otherwise silently corrupt
+// the composed selector.
+func (b *FieldSelectorBuilder) Build() string {
+	if len(b.clauses) == 0 {
+		return ""
+	}
+	parts := make([]string, len(b.clauses))
+	for i, c := range b.clauses {
+		parts[i] = fmt.Sprintf("%s=%s", c.field, c.value)
+	}
+	joined := strings.Join(parts, ",")
+	result := fields.ParseSelectorOrDie(joined).String()
+
+	for _, c := range b.clauses {
+		if recovered := recoverFieldSelectorValue(result, c.field); recovered != c.value {
+			panic(fmt.Sprintf("Could not recover field selector clause '%s' from builder. Expected '%s' but got '%s'\n", c.field, c.value, recovered))
+		}
+	}
+	return result
+}
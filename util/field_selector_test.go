@@ -0,0 +1,41 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFieldSelectorBuilderEmpty(t *testing.T) {
+	assert.Equal(t, "", NewFieldSelectorBuilder().Build())
+}
+
+func TestFieldSelectorBuilderSingleName(t *testing.T) {
+	selector := NewFieldSelectorBuilder().WithName("my-wf").Build()
+	assert.Equal(t, "my-wf", RecoverWorkflowNameFromSelectorStringIfAny(selector))
+}
+
+func TestFieldSelectorBuilderComposesWithAndSemantics(t *testing.T) {
+	selector := NewFieldSelectorBuilder().
+		WithName("my-wf").
+		WithNamespace("argo").
+		WithPhase("Running").
+		Build()
+
+	assert.Equal(t, "my-wf", recoverFieldSelectorValue(selector, "metadata.name"))
+	assert.Equal(t, "argo", recoverFieldSelectorValue(selector, "metadata.namespace"))
+	assert.Equal(t, "Running", recoverFieldSelectorValue(selector, "status.phase"))
+}
+
+func TestFieldSelectorBuilderIgnoresEmptyClauses(t *testing.T) {
+	selector := NewFieldSelectorBuilder().WithName("my-wf").WithNamespace("").Build()
+	assert.Equal(t, "", recoverFieldSelectorValue(selector, "metadata.namespace"))
+}
+
+func TestFieldSelectorBuilderPanicsOnUnrecoverableValue(t *testing.T) {
+	// A value containing a comma corrupts the composed selector: it gets split into a bogus extra
+	// clause, so the value recovered for "metadata.name" no longer matches what was passed in.
+	assert.Panics(t, func() {
+		NewFieldSelectorBuilder().WithName("my-wf,evil=true").Build()
+	})
+}
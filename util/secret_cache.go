@@ -0,0 +1,213 @@
+package util
+
+import (
+	"container/list"
+	"context"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// secretCacheTTLEnvVar configures how long a cached secret value is considered fresh.
+const secretCacheTTLEnvVar = "ARGO_SECRET_CACHE_TTL"
+
+// defaultSecretCacheTTL is used when ARGO_SECRET_CACHE_TTL is unset or invalid.
+const defaultSecretCacheTTL = 60 * time.Second
+
+// secretCacheMaxEntriesEnvVar configures the maximum number of distinct (namespace, name) secrets
+// held in memory at once, so that one-off template invocations referencing many secrets can't
+// grow the cache without bound.
+const secretCacheMaxEntriesEnvVar = "ARGO_SECRET_CACHE_MAX_ENTRIES"
+
+// defaultSecretCacheMaxEntries is used when ARGO_SECRET_CACHE_MAX_ENTRIES is unset or invalid.
+const defaultSecretCacheMaxEntries = 1024
+
+var (
+	secretCacheHits = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "argo_secret_cache_hits_total",
+		Help: "Number of GetSecrets calls served from the in-process secret cache",
+	})
+	secretCacheMisses = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "argo_secret_cache_misses_total",
+		Help: "Number of GetSecrets calls that required a live fetch from the backing SecretProvider",
+	})
+	secretCacheEvictions = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "argo_secret_cache_evictions_total",
+		Help: "Number of secret cache entries evicted, either by the LRU bound or by the informer invalidator",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(secretCacheHits, secretCacheMisses, secretCacheEvictions)
+}
+
+type secretCacheKey struct {
+	namespace string
+	name      string
+}
+
+type secretCacheEntry struct {
+	data      map[string][]byte
+	fetchedAt time.Time
+	elem      *list.Element
+}
+
+// secretCache is a goroutine-safe, TTL-bounded, LRU-bounded cache of secret data (as resolved by a
+// SecretProvider) used by GetSecrets. It is safe for concurrent use.
+type secretCache struct {
+	mu         sync.RWMutex
+	ttl        time.Duration
+	maxEntries int
+	entries    map[secretCacheKey]*secretCacheEntry
+	lru        *list.List // front = most recently used
+}
+
+func newSecretCache(ttl time.Duration, maxEntries int) *secretCache {
+	return &secretCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[secretCacheKey]*secretCacheEntry),
+		lru:        list.New(),
+	}
+}
+
+var defaultSecretCache = newSecretCache(secretCacheTTLFromEnv(), secretCacheMaxEntriesFromEnv())
+
+func secretCacheTTLFromEnv() time.Duration {
+	if raw, ok := os.LookupEnv(secretCacheTTLEnvVar); ok && raw != "" {
+		if secs, err := strconv.Atoi(raw); err == nil && secs >= 0 {
+			return time.Duration(secs) * time.Second
+		}
+		log.Warnf("Invalid %s value %q, falling back to default of %s", secretCacheTTLEnvVar, raw, defaultSecretCacheTTL)
+	}
+	return defaultSecretCacheTTL
+}
+
+func secretCacheMaxEntriesFromEnv() int {
+	if raw, ok := os.LookupEnv(secretCacheMaxEntriesEnvVar); ok && raw != "" {
+		if n, err := strconv.Atoi(raw); err == nil && n >= 0 {
+			return n
+		}
+		log.Warnf("Invalid %s value %q, falling back to default of %d", secretCacheMaxEntriesEnvVar, raw, defaultSecretCacheMaxEntries)
+	}
+	return defaultSecretCacheMaxEntries
+}
+
+// get returns the cached secret data for (namespace, name) if present and not expired.
+func (c *secretCache) get(namespace, name string) (map[string][]byte, bool) {
+	key := secretCacheKey{namespace, name}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok {
+		secretCacheMisses.Inc()
+		return nil, false
+	}
+	if c.ttl > 0 && time.Since(entry.fetchedAt) > c.ttl {
+		c.removeLocked(key)
+		secretCacheMisses.Inc()
+		return nil, false
+	}
+	c.lru.MoveToFront(entry.elem)
+	secretCacheHits.Inc()
+	return entry.data, true
+}
+
+// set stores secret data for (namespace, name), evicting the least-recently-used entry if the
+// cache is at capacity.
+func (c *secretCache) set(namespace, name string, data map[string][]byte) {
+	key := secretCacheKey{namespace, name}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if entry, ok := c.entries[key]; ok {
+		entry.data = data
+		entry.fetchedAt = time.Now()
+		c.lru.MoveToFront(entry.elem)
+		return
+	}
+	entry := &secretCacheEntry{data: data, fetchedAt: time.Now()}
+	entry.elem = c.lru.PushFront(key)
+	c.entries[key] = entry
+	for c.maxEntries > 0 && len(c.entries) > c.maxEntries {
+		oldest := c.lru.Back()
+		if oldest == nil {
+			break
+		}
+		c.removeLocked(oldest.Value.(secretCacheKey))
+		secretCacheEvictions.Inc()
+	}
+}
+
+// invalidate evicts the cache entry for (namespace, name), if any.
+func (c *secretCache) invalidate(namespace, name string) {
+	key := secretCacheKey{namespace, name}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; ok {
+		c.removeLocked(key)
+		secretCacheEvictions.Inc()
+	}
+}
+
+// removeLocked removes key from the cache. c.mu must be held for writing.
+func (c *secretCache) removeLocked(key secretCacheKey) {
+	if entry, ok := c.entries[key]; ok {
+		c.lru.Remove(entry.elem)
+		delete(c.entries, key)
+	}
+}
+
+// StartSecretCacheInvalidator watches Secrets in the given namespaces and evicts the in-process
+// cache entry populated by GetSecrets whenever the underlying Secret is updated or deleted, so
+// that rotated credentials take effect immediately rather than waiting out the TTL. It returns
+// once the informers' caches have synced; the informers themselves keep running until ctx is
+// cancelled.
+func StartSecretCacheInvalidator(ctx context.Context, clientSet kubernetes.Interface, namespaces []string) error {
+	var syncFuncs []cache.InformerSynced
+	for _, ns := range namespaces {
+		factory := informers.NewSharedInformerFactoryWithOptions(clientSet, 0, informers.WithNamespace(ns))
+		informer := factory.Core().V1().Secrets().Informer()
+		_, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+			UpdateFunc: func(_, newObj interface{}) {
+				invalidateSecretObj(newObj)
+			},
+			DeleteFunc: func(obj interface{}) {
+				invalidateSecretObj(obj)
+			},
+		})
+		if err != nil {
+			return err
+		}
+		factory.Start(ctx.Done())
+		syncFuncs = append(syncFuncs, informer.HasSynced)
+	}
+	if !cache.WaitForCacheSync(ctx.Done(), syncFuncs...) {
+		return ctx.Err()
+	}
+	return nil
+}
+
+func invalidateSecretObj(obj interface{}) {
+	secret, ok := obj.(*apiv1.Secret)
+	if !ok {
+		if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+			secret, ok = tombstone.Obj.(*apiv1.Secret)
+			if !ok {
+				return
+			}
+		} else {
+			return
+		}
+	}
+	// The default Kubernetes provider is always registered under the "" prefix (see
+	// ensureDefaultKubernetesProvider), so its cache entries are keyed accordingly.
+	defaultSecretCache.invalidate(secret.Namespace, secretCacheName("", secret.Name))
+}
@@ -0,0 +1,122 @@
+package util
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestSecretCacheGetSetMiss(t *testing.T) {
+	c := newSecretCache(time.Minute, 10)
+
+	_, ok := c.get("default", "my-secret")
+	assert.False(t, ok)
+
+	c.set("default", "my-secret", map[string][]byte{"password": []byte("hunter2")})
+	data, ok := c.get("default", "my-secret")
+	assert.True(t, ok)
+	assert.Equal(t, []byte("hunter2"), data["password"])
+}
+
+func TestSecretCacheTTLExpiry(t *testing.T) {
+	c := newSecretCache(time.Millisecond, 10)
+	c.set("default", "my-secret", map[string][]byte{"password": []byte("hunter2")})
+
+	time.Sleep(5 * time.Millisecond)
+
+	_, ok := c.get("default", "my-secret")
+	assert.False(t, ok, "expired entry should be evicted on read")
+}
+
+func TestSecretCacheZeroTTLNeverExpires(t *testing.T) {
+	c := newSecretCache(0, 10)
+	c.set("default", "my-secret", map[string][]byte{"password": []byte("hunter2")})
+
+	time.Sleep(5 * time.Millisecond)
+
+	_, ok := c.get("default", "my-secret")
+	assert.True(t, ok)
+}
+
+func TestSecretCacheLRUEviction(t *testing.T) {
+	c := newSecretCache(time.Minute, 2)
+
+	c.set("default", "a", map[string][]byte{"k": []byte("a")})
+	c.set("default", "b", map[string][]byte{"k": []byte("b")})
+	// touch "a" so "b" becomes the least-recently-used entry
+	_, _ = c.get("default", "a")
+	c.set("default", "c", map[string][]byte{"k": []byte("c")})
+
+	_, ok := c.get("default", "b")
+	assert.False(t, ok, "least-recently-used entry should have been evicted")
+
+	_, ok = c.get("default", "a")
+	assert.True(t, ok)
+	_, ok = c.get("default", "c")
+	assert.True(t, ok)
+}
+
+func TestSecretCacheInvalidate(t *testing.T) {
+	c := newSecretCache(time.Minute, 10)
+	c.set("default", "my-secret", map[string][]byte{"password": []byte("hunter2")})
+
+	c.invalidate("default", "my-secret")
+
+	_, ok := c.get("default", "my-secret")
+	assert.False(t, ok)
+}
+
+func TestSecretCacheNamespaceIsolation(t *testing.T) {
+	c := newSecretCache(time.Minute, 10)
+	c.set("ns-a", "my-secret", map[string][]byte{"k": []byte("a")})
+	c.set("ns-b", "my-secret", map[string][]byte{"k": []byte("b")})
+
+	dataA, ok := c.get("ns-a", "my-secret")
+	assert.True(t, ok)
+	assert.Equal(t, []byte("a"), dataA["k"])
+
+	dataB, ok := c.get("ns-b", "my-secret")
+	assert.True(t, ok)
+	assert.Equal(t, []byte("b"), dataB["k"])
+}
+
+// TestStartSecretCacheInvalidatorEvictsOnUpdateAndDelete exercises the informer wiring against a
+// fake clientset end-to-end: a cache entry populated out-of-band (the way GetSecrets would
+// populate it) must be evicted as soon as the underlying Secret is updated or deleted, without
+// waiting for the TTL.
+func TestStartSecretCacheInvalidatorEvictsOnUpdateAndDelete(t *testing.T) {
+	secret := &apiv1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "default"},
+		Data:       map[string][]byte{"password": []byte("hunter2")},
+	}
+	clientSet := fake.NewSimpleClientset(secret)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	assert.NoError(t, StartSecretCacheInvalidator(ctx, clientSet, []string{"default"}))
+
+	cacheName := secretCacheName("", "db")
+	t.Cleanup(func() { defaultSecretCache.invalidate("default", cacheName) })
+
+	defaultSecretCache.set("default", cacheName, map[string][]byte{"password": []byte("hunter2")})
+	updated := secret.DeepCopy()
+	updated.Data["password"] = []byte("rotated")
+	_, err := clientSet.CoreV1().Secrets("default").Update(ctx, updated, metav1.UpdateOptions{})
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		_, ok := defaultSecretCache.get("default", cacheName)
+		return !ok
+	}, time.Second, 5*time.Millisecond, "cache entry should be evicted after a Secret update")
+
+	defaultSecretCache.set("default", cacheName, map[string][]byte{"password": []byte("rotated")})
+	assert.NoError(t, clientSet.CoreV1().Secrets("default").Delete(ctx, "db", metav1.DeleteOptions{}))
+	assert.Eventually(t, func() bool {
+		_, ok := defaultSecretCache.get("default", cacheName)
+		return !ok
+	}, time.Second, 5*time.Millisecond, "cache entry should be evicted after a Secret delete")
+}
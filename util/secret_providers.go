@@ -0,0 +1,388 @@
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	apierr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/argoproj/argo/v2/errors"
+)
+
+// SecretRef identifies a secret object within a backing store, independent of which store it
+// lives in.
+type SecretRef struct {
+	// Namespace is the Kubernetes namespace the secret is scoped to. Only the default Kubernetes
+	// provider uses it; other backing stores (Vault, file, HTTP) are not namespace-scoped.
+	Namespace string
+	// Name is the provider-specific identifier of the secret, e.g. a Kubernetes Secret name, or
+	// a Vault path such as "kv/team/db".
+	Name string
+}
+
+// SecretProvider resolves a SecretRef to the full set of key/value pairs it holds, mirroring
+// apiv1.Secret.Data, so that a single fetch can satisfy lookups of multiple keys from the same
+// underlying secret and those keys can be cached together. Implementations are expected to share
+// GetSecrets' in-process cache and retry/backoff behavior, and must respect ctx cancellation.
+type SecretProvider interface {
+	// Name returns the provider's prefix, e.g. "vault" or "file". The default Kubernetes provider
+	// uses the empty string.
+	Name() string
+	Resolve(ctx context.Context, ref SecretRef) (map[string][]byte, error)
+}
+
+var (
+	secretProvidersMu sync.RWMutex
+	// secretProviders holds the registered providers, keyed by prefix ("" for the default
+	// Kubernetes provider, lazily registered by GetSecrets via ensureDefaultKubernetesProvider).
+	secretProviders = map[string]SecretProvider{}
+)
+
+// RegisterSecretProvider registers a SecretProvider under its own Name(). Providers registered
+// under the same prefix replace one another; this is primarily used by tests to swap in fakes.
+func RegisterSecretProvider(p SecretProvider) {
+	secretProvidersMu.Lock()
+	defer secretProvidersMu.Unlock()
+	secretProviders[p.Name()] = p
+}
+
+// lookupSecretProvider returns the provider registered under prefix, or nil if none is.
+func lookupSecretProvider(prefix string) SecretProvider {
+	secretProvidersMu.RLock()
+	defer secretProvidersMu.RUnlock()
+	return secretProviders[prefix]
+}
+
+// secretCacheName renders the cache key used for a resolved (prefix, rest) pair, as produced by
+// ParseSecretName. It is shared by GetSecrets (to populate the cache) and the informer-driven
+// invalidator (to evict the matching entry), so the two always agree on cache key format.
+func secretCacheName(prefix, rest string) string {
+	return prefix + ":" + rest
+}
+
+// ParseSecretName splits a possibly-prefixed secret name (e.g. "vault:kv/team/db") into its
+// provider prefix and the remaining provider-specific name. A name with no recognized prefix is
+// returned unchanged with an empty prefix, which resolves to the default Kubernetes provider.
+func ParseSecretName(name string) (prefix string, rest string) {
+	if idx := strings.Index(name, ":"); idx > 0 {
+		candidate := name[:idx]
+		secretProvidersMu.RLock()
+		_, ok := secretProviders[candidate]
+		secretProvidersMu.RUnlock()
+		if ok {
+			return candidate, name[idx+1:]
+		}
+	}
+	return "", name
+}
+
+// ensureDefaultKubernetesProvider registers a KubernetesSecretProvider under the empty prefix, or
+// repoints the already-registered one at clientSet if one exists. clientSet is a GetSecrets
+// parameter rather than something wired up once at startup, so this runs on every call and keeps
+// the provider in sync with whichever clientSet the caller is currently passing (e.g. after
+// credential rotation, or across independent callers/tests using different clientsets in the same
+// process) instead of latching onto the first one seen.
+func ensureDefaultKubernetesProvider(clientSet kubernetes.Interface) {
+	secretProvidersMu.Lock()
+	existing, ok := secretProviders[""].(*KubernetesSecretProvider)
+	if !ok {
+		existing = &KubernetesSecretProvider{}
+		secretProviders[""] = existing
+	}
+	secretProvidersMu.Unlock()
+	existing.setClientSet(clientSet)
+}
+
+// KubernetesSecretProvider is the default SecretProvider, backed by a live Kubernetes Secret
+// lookup. It is registered under the empty prefix by ensureDefaultKubernetesProvider, which also
+// repoints clientSet on every GetSecrets call (see ensureDefaultKubernetesProvider); clientSet is
+// therefore guarded by its own mutex rather than secretProvidersMu, since Resolve reads it without
+// holding secretProvidersMu.
+type KubernetesSecretProvider struct {
+	clientSetMu sync.RWMutex
+	clientSet   kubernetes.Interface
+}
+
+func (p *KubernetesSecretProvider) Name() string { return "" }
+
+func (p *KubernetesSecretProvider) setClientSet(clientSet kubernetes.Interface) {
+	p.clientSetMu.Lock()
+	defer p.clientSetMu.Unlock()
+	p.clientSet = clientSet
+}
+
+func (p *KubernetesSecretProvider) Resolve(ctx context.Context, ref SecretRef) (map[string][]byte, error) {
+	p.clientSetMu.RLock()
+	clientSet := p.clientSet
+	p.clientSetMu.RUnlock()
+	secret, err := clientSet.CoreV1().Secrets(ref.Namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return secret.Data, nil
+}
+
+// SecretProviderConfig is the shape of a per-workflow secret provider override, read from the
+// "default" field of the "<workflow-name>-secret-provider" ConfigMap via LoadSecretProviderConfig,
+// so that a workflow can be routed to a non-default provider without prefixing every secret name
+// it references.
+type SecretProviderConfig struct {
+	// Default is the provider prefix (see SecretProvider.Name) to use for secret names that carry
+	// no explicit prefix. Empty means the default Kubernetes provider.
+	Default string `json:"default,omitempty"`
+}
+
+// secretProviderConfigMapName returns the name of the ConfigMap a workflow's secret provider
+// override is read from.
+func secretProviderConfigMapName(workflowName string) string {
+	return workflowName + "-secret-provider"
+}
+
+// LoadSecretProviderConfig reads the per-workflow SecretProviderConfig from the
+// "<workflowName>-secret-provider" ConfigMap in namespace. If that ConfigMap does not exist, it
+// returns a zero-value SecretProviderConfig (i.e. no override) and a nil error.
+func LoadSecretProviderConfig(ctx context.Context, clientSet kubernetes.Interface, namespace, workflowName string) (SecretProviderConfig, error) {
+	cm, err := clientSet.CoreV1().ConfigMaps(namespace).Get(ctx, secretProviderConfigMapName(workflowName), metav1.GetOptions{})
+	if err != nil {
+		if apierr.IsNotFound(err) {
+			return SecretProviderConfig{}, nil
+		}
+		return SecretProviderConfig{}, errors.InternalWrapError(err)
+	}
+	return SecretProviderConfig{Default: cm.Data["default"]}, nil
+}
+
+// GetSecretsWithConfig behaves like GetSecrets, but falls back to cfg.Default instead of the
+// Kubernetes provider when name carries no recognized prefix. Use LoadSecretProviderConfig to
+// build cfg from the workflow's ConfigMap override.
+func GetSecretsWithConfig(ctx context.Context, clientSet kubernetes.Interface, cfg SecretProviderConfig, namespace, name, key string) ([]byte, error) {
+	if prefix, _ := ParseSecretName(name); prefix == "" && cfg.Default != "" {
+		name = cfg.Default + ":" + name
+	}
+	return GetSecrets(ctx, clientSet, namespace, name, key)
+}
+
+// fileSecretRootEnvVar configures the root directory file-mounted secrets are read from, e.g. via
+// a CSI Secrets Store volume or a projected volume. Layout is <root>/<name>/<key>.
+const fileSecretRootEnvVar = "ARGO_FILE_SECRET_ROOT"
+
+const defaultFileSecretRoot = "/var/run/argo/secrets"
+
+// FileSecretProvider resolves secrets from files mounted by a CSI Secrets Store driver or a
+// projected volume, one file per key under Root+"/"+ref.Name. Register it with the "file:" prefix
+// to enable names like "file:db" (combined with the separate key argument GetSecrets already
+// takes, e.g. key "password" selects Root+"/db/password").
+type FileSecretProvider struct {
+	Root string
+}
+
+func NewFileSecretProvider() *FileSecretProvider {
+	root := os.Getenv(fileSecretRootEnvVar)
+	if root == "" {
+		root = defaultFileSecretRoot
+	}
+	return &FileSecretProvider{Root: root}
+}
+
+func (p *FileSecretProvider) Name() string { return "file" }
+
+func (p *FileSecretProvider) Resolve(ctx context.Context, ref SecretRef) (map[string][]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	dir := filepath.Join(p.Root, ref.Name)
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Errorf(errors.CodeBadRequest, "could not read file-mounted secret dir '%s': %v", dir, err)
+	}
+	data := make(map[string][]byte, len(entries))
+	for _, entry := range entries {
+		// Secret/ConfigMap volumes use the atomic-writer layout: a real "..<timestamp>" directory,
+		// a "..data" symlink pointing at it, and per-key symlinks resolved through "..data". Skip
+		// anything starting with ".." (the standard convention for reading such volumes) rather
+		// than checking IsDir(), since the "..data" symlink itself is not a directory and would
+		// otherwise be opened as a regular file and fail with "is a directory".
+		if strings.HasPrefix(entry.Name(), "..") {
+			continue
+		}
+		if entry.IsDir() {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		val, err := ioutil.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, errors.Errorf(errors.CodeBadRequest, "could not read file-mounted secret key '%s/%s': %v", ref.Name, entry.Name(), err)
+		}
+		data[entry.Name()] = val
+	}
+	return data, nil
+}
+
+// VaultSecretProvider resolves secrets from HashiCorp Vault's KV v2 engine. Authentication is via
+// the pod's Kubernetes service-account JWT against auth/kubernetes/login; the resulting client
+// token is then used to GET <mount>/data/<path>, returning the full data.data payload for
+// GetSecrets to pick a key out of. Secret names take the form "<mount>/<path>", e.g. "kv/team/db".
+type VaultSecretProvider struct {
+	// Addr is the Vault server address, e.g. "https://vault.vault.svc:8200".
+	Addr string
+	// Role is the Vault Kubernetes auth role to authenticate as.
+	Role string
+	// SAJWTPath is the path to the pod's projected service-account token.
+	SAJWTPath string
+
+	httpClient *http.Client
+}
+
+func NewVaultSecretProvider(addr, role string) *VaultSecretProvider {
+	return &VaultSecretProvider{
+		Addr:       addr,
+		Role:       role,
+		SAJWTPath:  "/var/run/secrets/kubernetes.io/serviceaccount/token",
+		httpClient: http.DefaultClient,
+	}
+}
+
+func (p *VaultSecretProvider) Name() string { return "vault" }
+
+func (p *VaultSecretProvider) Resolve(ctx context.Context, ref SecretRef) (map[string][]byte, error) {
+	token, err := p.login(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	mount, path, err := splitVaultName(ref.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	url := fmt.Sprintf("%s/v1/%s/data/%s", strings.TrimRight(p.Addr, "/"), mount, path)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", token)
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("vault returned status %d reading %s/%s", resp.StatusCode, mount, path)
+	}
+
+	var body struct {
+		Data struct {
+			Data map[string]interface{} `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	data := make(map[string][]byte, len(body.Data.Data))
+	for k, v := range body.Data.Data {
+		data[k] = []byte(fmt.Sprintf("%v", v))
+	}
+	return data, nil
+}
+
+func splitVaultName(name string) (mount, path string, err error) {
+	idx := strings.Index(name, "/")
+	if idx <= 0 {
+		return "", "", errors.Errorf(errors.CodeBadRequest, "vault secret name '%s' must be of the form '<mount>/<path>'", name)
+	}
+	return name[:idx], name[idx+1:], nil
+}
+
+func (p *VaultSecretProvider) login(ctx context.Context) (string, error) {
+	jwt, err := ioutil.ReadFile(p.SAJWTPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read service account token for vault login: %w", err)
+	}
+
+	reqBody, err := json.Marshal(map[string]string{
+		"jwt":  strings.TrimSpace(string(jwt)),
+		"role": p.Role,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	url := fmt.Sprintf("%s/v1/auth/kubernetes/login", strings.TrimRight(p.Addr, "/"))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, strings.NewReader(string(reqBody)))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("vault kubernetes login returned status %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Auth struct {
+			ClientToken string `json:"client_token"`
+		} `json:"auth"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", err
+	}
+	return body.Auth.ClientToken, nil
+}
+
+// HTTPSecretProvider resolves secrets via a generic HTTP sidecar, such as the AWS or GCP Secret
+// Manager CSI/agent sidecars, which expose a "GET <BaseURL>/<name>" endpoint returning a JSON
+// object of key/value pairs.
+type HTTPSecretProvider struct {
+	Prefix     string
+	BaseURL    string
+	httpClient *http.Client
+}
+
+func NewHTTPSecretProvider(prefix, baseURL string) *HTTPSecretProvider {
+	return &HTTPSecretProvider{Prefix: prefix, BaseURL: baseURL, httpClient: http.DefaultClient}
+}
+
+func (p *HTTPSecretProvider) Name() string { return p.Prefix }
+
+func (p *HTTPSecretProvider) Resolve(ctx context.Context, ref SecretRef) (map[string][]byte, error) {
+	url := fmt.Sprintf("%s/%s", strings.TrimRight(p.BaseURL, "/"), ref.Name)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("secret manager sidecar returned status %d reading '%s'", resp.StatusCode, ref.Name)
+	}
+
+	var values map[string]string
+	if err := json.NewDecoder(resp.Body).Decode(&values); err != nil {
+		return nil, err
+	}
+	data := make(map[string][]byte, len(values))
+	for k, v := range values {
+		data[k] = []byte(v)
+	}
+	return data, nil
+}
@@ -0,0 +1,256 @@
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+type fakeSecretProvider struct {
+	name string
+}
+
+func (f *fakeSecretProvider) Name() string { return f.name }
+
+func (f *fakeSecretProvider) Resolve(ctx context.Context, ref SecretRef) (map[string][]byte, error) {
+	return map[string][]byte{"key": []byte(ref.Name)}, nil
+}
+
+// registerSecretProviderForTest registers p and restores whatever was previously registered under
+// p.Name() (if anything) once the test completes, so tests don't leak fake providers into the
+// package-global registry for the rest of the test binary.
+func registerSecretProviderForTest(t *testing.T, p SecretProvider) {
+	t.Helper()
+	prev := lookupSecretProvider(p.Name())
+	RegisterSecretProvider(p)
+	t.Cleanup(func() {
+		secretProvidersMu.Lock()
+		defer secretProvidersMu.Unlock()
+		if prev == nil {
+			delete(secretProviders, p.Name())
+		} else {
+			secretProviders[p.Name()] = prev
+		}
+	})
+}
+
+func TestParseSecretNameRecognizedPrefix(t *testing.T) {
+	registerSecretProviderForTest(t, &fakeSecretProvider{name: "vault"})
+
+	prefix, rest := ParseSecretName("vault:kv/team/db")
+	assert.Equal(t, "vault", prefix)
+	assert.Equal(t, "kv/team/db", rest)
+}
+
+func TestParseSecretNameUnrecognizedPrefixFallsBackToDefault(t *testing.T) {
+	// "unknown:" is not a registered provider prefix, so the whole string is treated as a literal
+	// (Kubernetes) secret name rather than being split.
+	prefix, rest := ParseSecretName("unknown:my-secret")
+	assert.Equal(t, "", prefix)
+	assert.Equal(t, "unknown:my-secret", rest)
+}
+
+func TestParseSecretNameNoPrefix(t *testing.T) {
+	prefix, rest := ParseSecretName("my-secret")
+	assert.Equal(t, "", prefix)
+	assert.Equal(t, "my-secret", rest)
+}
+
+func TestParseSecretNameLeadingColonIsNotAPrefix(t *testing.T) {
+	// A leading ':' has no candidate prefix before it, so it must not match any registered
+	// provider (Index returns 0, which the "> 0" check in ParseSecretName rejects).
+	prefix, rest := ParseSecretName(":kv/team/db")
+	assert.Equal(t, "", prefix)
+	assert.Equal(t, ":kv/team/db", rest)
+}
+
+// writeAtomicWriterSecret lays dir out the way kubelet mounts a Secret/ConfigMap volume: a real
+// "..<timestamp>" directory holding the keys, a "..data" symlink pointing at it, and a per-key
+// symlink through "..data", so tests can exercise FileSecretProvider.Resolve against the same
+// shape a CSI Secrets Store or projected volume produces in production.
+func writeAtomicWriterSecret(t *testing.T, dir string, data map[string]string) {
+	t.Helper()
+	timestampDir := filepath.Join(dir, "..2020_01_01_00_00_00.000000000")
+	assert.NoError(t, os.MkdirAll(timestampDir, 0755))
+	for key, val := range data {
+		assert.NoError(t, os.WriteFile(filepath.Join(timestampDir, key), []byte(val), 0644))
+	}
+	assert.NoError(t, os.Symlink(filepath.Base(timestampDir), filepath.Join(dir, "..data")))
+	for key := range data {
+		assert.NoError(t, os.Symlink(filepath.Join("..data", key), filepath.Join(dir, key)))
+	}
+}
+
+func TestFileSecretProviderResolveAtomicWriterLayout(t *testing.T) {
+	root := t.TempDir()
+	writeAtomicWriterSecret(t, filepath.Join(root, "db"), map[string]string{"password": "hunter2"})
+
+	p := &FileSecretProvider{Root: root}
+	data, err := p.Resolve(context.Background(), SecretRef{Name: "db"})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("hunter2"), data["password"])
+	// The "..data" symlink and the real "..<timestamp>" directory must not show up as keys.
+	_, ok := data["..data"]
+	assert.False(t, ok)
+	_, ok = data["..2020_01_01_00_00_00.000000000"]
+	assert.False(t, ok)
+}
+
+func TestFileSecretProviderResolveMissingDir(t *testing.T) {
+	p := &FileSecretProvider{Root: t.TempDir()}
+	_, err := p.Resolve(context.Background(), SecretRef{Name: "does-not-exist"})
+	assert.Error(t, err)
+}
+
+func TestVaultSecretProviderResolve(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/auth/kubernetes/login":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"auth": map[string]string{"client_token": "root-token"},
+			})
+		case "/v1/kv/data/team/db":
+			assert.Equal(t, "root-token", r.Header.Get("X-Vault-Token"))
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"data": map[string]interface{}{
+					"data": map[string]interface{}{"password": "hunter2"},
+				},
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	jwtPath := filepath.Join(t.TempDir(), "token")
+	assert.NoError(t, os.WriteFile(jwtPath, []byte("fake-jwt"), 0600))
+
+	p := NewVaultSecretProvider(server.URL, "argo")
+	p.SAJWTPath = jwtPath
+
+	data, err := p.Resolve(context.Background(), SecretRef{Name: "kv/team/db"})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("hunter2"), data["password"])
+}
+
+func TestSplitVaultNameRejectsMissingSlash(t *testing.T) {
+	_, _, err := splitVaultName("no-slash-in-this-name")
+	assert.Error(t, err)
+}
+
+func TestSplitVaultNameSplitsMountFromPath(t *testing.T) {
+	mount, path, err := splitVaultName("kv/team/db")
+	assert.NoError(t, err)
+	assert.Equal(t, "kv", mount)
+	assert.Equal(t, "team/db", path)
+}
+
+func TestHTTPSecretProviderResolve(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/db", r.URL.Path)
+		_ = json.NewEncoder(w).Encode(map[string]string{"password": "hunter2"})
+	}))
+	defer server.Close()
+
+	p := NewHTTPSecretProvider("secretsmanager", server.URL)
+	assert.Equal(t, "secretsmanager", p.Name())
+
+	data, err := p.Resolve(context.Background(), SecretRef{Name: "db"})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("hunter2"), data["password"])
+}
+
+func TestLoadSecretProviderConfigNotFound(t *testing.T) {
+	clientSet := fake.NewSimpleClientset()
+	cfg, err := LoadSecretProviderConfig(context.Background(), clientSet, "default", "my-wf")
+	assert.NoError(t, err)
+	assert.Equal(t, SecretProviderConfig{}, cfg)
+}
+
+func TestLoadSecretProviderConfigFound(t *testing.T) {
+	clientSet := fake.NewSimpleClientset(&apiv1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-wf-secret-provider", Namespace: "default"},
+		Data:       map[string]string{"default": "vault"},
+	})
+	cfg, err := LoadSecretProviderConfig(context.Background(), clientSet, "default", "my-wf")
+	assert.NoError(t, err)
+	assert.Equal(t, SecretProviderConfig{Default: "vault"}, cfg)
+}
+
+func TestGetSecretsWithConfigUsesConfigDefaultForUnprefixedNames(t *testing.T) {
+	registerSecretProviderForTest(t, &fakeSecretProvider{name: "vault"})
+	clientSet := fake.NewSimpleClientset()
+
+	val, err := GetSecretsWithConfig(context.Background(), clientSet, SecretProviderConfig{Default: "vault"}, "default", "db", "key")
+	assert.NoError(t, err)
+	// fakeSecretProvider.Resolve echoes back the (prefix-stripped) name it was asked to resolve.
+	assert.Equal(t, []byte("db"), val)
+}
+
+func TestGetSecretsEndToEndPopulatesAndReusesCache(t *testing.T) {
+	registerSecretProviderForTest(t, &fakeSecretProvider{name: "vault"})
+	clientSet := fake.NewSimpleClientset()
+
+	val, err := GetSecrets(context.Background(), clientSet, "default", "vault:kv/team/db", "key")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("kv/team/db"), val)
+
+	cached, ok := defaultSecretCache.get("default", secretCacheName("vault", "kv/team/db"))
+	assert.True(t, ok, "GetSecrets should have populated the cache on miss")
+	assert.Equal(t, []byte("kv/team/db"), cached["key"])
+	t.Cleanup(func() { defaultSecretCache.invalidate("default", secretCacheName("vault", "kv/team/db")) })
+
+	// A second call must be served from the cache rather than hitting the provider again; swap in
+	// a provider that errors to prove it's not consulted.
+	registerSecretProviderForTest(t, &erroringSecretProvider{name: "vault"})
+	val, err = GetSecrets(context.Background(), clientSet, "default", "vault:kv/team/db", "key")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("kv/team/db"), val)
+}
+
+type erroringSecretProvider struct {
+	name string
+}
+
+func (f *erroringSecretProvider) Name() string { return f.name }
+
+func (f *erroringSecretProvider) Resolve(ctx context.Context, ref SecretRef) (map[string][]byte, error) {
+	return nil, fmt.Errorf("erroringSecretProvider: Resolve should not be called for a cache hit")
+}
+
+func TestEnsureDefaultKubernetesProviderRepointsStaleClientSet(t *testing.T) {
+	prev := lookupSecretProvider("")
+	t.Cleanup(func() {
+		secretProvidersMu.Lock()
+		defer secretProvidersMu.Unlock()
+		if prev == nil {
+			delete(secretProviders, "")
+		} else {
+			secretProviders[""] = prev
+		}
+	})
+
+	first := fake.NewSimpleClientset()
+	ensureDefaultKubernetesProvider(first)
+	second := fake.NewSimpleClientset(&apiv1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "default"},
+		Data:       map[string][]byte{"password": []byte("hunter2")},
+	})
+	ensureDefaultKubernetesProvider(second)
+
+	provider, ok := lookupSecretProvider("").(*KubernetesSecretProvider)
+	assert.True(t, ok)
+	data, err := provider.Resolve(context.Background(), SecretRef{Namespace: "default", Name: "db"})
+	assert.NoError(t, err, "provider should use the clientSet from the most recent call, not the first")
+	assert.Equal(t, []byte("hunter2"), data["password"])
+}
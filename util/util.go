@@ -11,7 +11,6 @@ import (
 	"k8s.io/apimachinery/pkg/fields"
 
 	log "github.com/sirupsen/logrus"
-	apiv1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes"
@@ -32,16 +31,40 @@ func Close(c Closer) {
 	_ = c.Close()
 }
 
-// GetSecrets retrieves a secret value and memoizes the result
+// GetSecrets retrieves a secret value through the SecretProvider registered for name's prefix (see
+// ParseSecretName), defaulting to a live Kubernetes Secret lookup when name carries no recognized
+// prefix (e.g. "vault:kv/team/db" resolves via the "vault" provider, "db" resolves via Kubernetes).
+// Results are memoized in an in-process, TTL-bounded cache keyed by (namespace, prefixed name), so
+// that repeated lookups of the same secret (e.g. from many concurrent template invocations) don't
+// each re-hit the backing store. The TTL defaults to 60s and is configurable via the
+// ARGO_SECRET_CACHE_TTL env var (in seconds). For the default Kubernetes provider, if
+// StartSecretCacheInvalidator has been started for the relevant namespace, entries are also
+// evicted as soon as the underlying Secret is updated or deleted, so rotated credentials take
+// effect without waiting for the TTL to expire.
 func GetSecrets(ctx context.Context, clientSet kubernetes.Interface, namespace, name, key string) ([]byte, error) {
+	ensureDefaultKubernetesProvider(clientSet)
 
-	secretsIf := clientSet.CoreV1().Secrets(namespace)
-	var secret *apiv1.Secret
+	prefix, rest := ParseSecretName(name)
+	provider := lookupSecretProvider(prefix)
+	if provider == nil {
+		return []byte{}, errors.Errorf(errors.CodeBadRequest, "no secret provider registered for prefix '%s'", prefix)
+	}
+
+	cacheName := secretCacheName(prefix, rest)
+	if data, ok := defaultSecretCache.get(namespace, cacheName); ok {
+		val, ok := data[key]
+		if !ok {
+			return []byte{}, errors.Errorf(errors.CodeBadRequest, "secret '%s' does not have the key '%s'", name, key)
+		}
+		return val, nil
+	}
+
+	var data map[string][]byte
 	var err error
 	_ = wait.ExponentialBackoff(retry.DefaultRetry, func() (bool, error) {
-		secret, err = secretsIf.Get(ctx, name, metav1.GetOptions{})
+		data, err = provider.Resolve(ctx, SecretRef{Namespace: namespace, Name: rest})
 		if err != nil {
-			log.Warnf("Failed to get secret '%s': %v", name, err)
+			log.Warnf("Failed to resolve secret '%s': %v", name, err)
 			if !errorsutil.IsTransientErr(err) {
 				return false, err
 			}
@@ -52,7 +75,8 @@ func GetSecrets(ctx context.Context, clientSet kubernetes.Interface, namespace,
 	if err != nil {
 		return []byte{}, errors.InternalWrapError(err)
 	}
-	val, ok := secret.Data[key]
+	defaultSecretCache.set(namespace, cacheName, data)
+	val, ok := data[key]
 	if !ok {
 		return []byte{}, errors.Errorf(errors.CodeBadRequest, "secret '%s' does not have the key '%s'", name, key)
 	}